@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// ClientOpt modifies a Client at construction time.
+type ClientOpt func(*Client) error
+
+// NewClient creates a new Client, applying opts in order. It returns the first error
+// encountered applying an opt, if any.
+func NewClient(opts ...ClientOpt) (*Client, error) {
+	c := &Client{}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Client compiles and evaluates ConstraintTemplates and Constraints.
+type Client struct {
+	// regoVersion is the default Rego version ConstraintTemplate source is
+	// parsed and compiled under. A template that declares `import rego.v1`
+	// always runs under ast.RegoV1 regardless of this setting.
+	regoVersion ast.RegoVersion
+
+	// inputSchema, if set, is the JSON schema `input` must conform to. Registered templates
+	// are type-checked against it at registration time.
+	inputSchema *ast.Schema
+
+	// dataSchemas maps a `data.<root>` field (e.g. "inventory") to the JSON schema its
+	// contents must conform to. A root present here is also implicitly allowed in
+	// checkDataAccess.
+	dataSchemas map[string]*ast.Schema
+
+	// dataRootProviders holds the Targets and external data providers that have registered a
+	// `data.<root>` namespace via AddDataRootProvider, keyed by root name. A root present here
+	// is implicitly allowed in checkDataAccess, in addition to the built-in `data.inventory`.
+	dataRootProviders map[string]DataRootProvider
+}
+
+// DataRootProvider is implemented by Targets and external data providers that make a
+// `data.<Name()>` namespace available to ConstraintTemplate rego. Registering one with
+// Client.AddDataRootProvider allows templates to reference that root without patching the
+// client, while unregistered roots remain rejected at template compile time.
+type DataRootProvider interface {
+	// Name is the `data.<root>` this provider makes available, e.g. "inventory" or
+	// "imagescancache".
+	Name() string
+}
+
+// SchemaDataRootProvider may optionally be implemented by a DataRootProvider to describe the
+// shape of the data under its root, so templates referencing it are type-checked at
+// registration time.
+type SchemaDataRootProvider interface {
+	DataRootProvider
+	Schema() *ast.Schema
+}
+
+// ResolvableDataRootProvider may optionally be implemented by a DataRootProvider that can
+// resolve its own data at evaluation time, e.g. a cache fed by an external data source.
+type ResolvableDataRootProvider interface {
+	DataRootProvider
+	Resolve(ctx context.Context, ref ast.Ref) (interface{}, error)
+}
+
+// AddDataRootProvider registers p's namespace (`data.<p.Name()>`) as a root ConstraintTemplate
+// rego may reference. If p also implements SchemaDataRootProvider, its schema is used to
+// type-check references under that root at template registration time.
+func (c *Client) AddDataRootProvider(p DataRootProvider) error {
+	name := p.Name()
+	if name == "" {
+		return errors.New("data root provider must have a non-empty Name()")
+	}
+	if c.dataRootProviders == nil {
+		c.dataRootProviders = make(map[string]DataRootProvider)
+	}
+	c.dataRootProviders[name] = p
+
+	if sp, ok := p.(SchemaDataRootProvider); ok {
+		if c.dataSchemas == nil {
+			c.dataSchemas = make(map[string]*ast.Schema)
+		}
+		c.dataSchemas[name] = sp.Schema()
+	}
+	return nil
+}
+
+// RegoVersion sets the default Rego version used for templates that do not
+// declare their own version via `import rego.v1`. The default is
+// ast.RegoV0, matching the framework's historical behavior.
+func RegoVersion(v ast.RegoVersion) ClientOpt {
+	return func(c *Client) error {
+		c.regoVersion = v
+		return nil
+	}
+}
+
+// WithInputSchema registers the JSON schema that `input` must conform to. Templates are
+// type-checked against it when registered, so references like
+// `input.review.object.spec.badField` are caught at registration time rather than at eval time.
+func WithInputSchema(schema *ast.Schema) ClientOpt {
+	return func(c *Client) error {
+		c.inputSchema = schema
+		return nil
+	}
+}
+
+// WithDataSchema registers the JSON schema that `data.<root>` must conform to, and implicitly
+// allows templates to reference that root (in addition to the built-in `data.inventory`).
+func WithDataSchema(root string, schema *ast.Schema) ClientOpt {
+	return func(c *Client) error {
+		if c.dataSchemas == nil {
+			c.dataSchemas = make(map[string]*ast.Schema)
+		}
+		c.dataSchemas[root] = schema
+		return nil
+	}
+}
@@ -11,26 +11,119 @@ import (
 // ensureRegoConformance rewrites the package path and ensures there is no access of `data`
 // beyond the whitelisted bits. Note that this rewriting will currently modify the Rego to look
 // potentially very different from the input, but it will still be functionally equivalent.
-func ensureRegoConformance(kind, path, rego string) (string, error) {
+//
+// c.regoVersion (set via the RegoVersion ClientOpt) is the Rego version requested by the
+// client. A template that declares `import rego.v1` always compiles under ast.RegoV1,
+// regardless of c.regoVersion. If c carries an input or data schema (via WithInputSchema /
+// WithDataSchema), the rewritten module is additionally type-checked against them.
+func (c *Client) ensureRegoConformance(kind, path, rego string) (string, error) {
 	if rego == "" {
 		return "", errors.New("Rego source code is empty")
 	}
-	module, err := ast.ParseModule(kind, rego)
+	module, err := ast.ParseModuleWithOpts(kind, rego, ast.ParserOptions{RegoVersion: c.regoVersion})
 	if err != nil {
 		return "", err
 	}
-	if len(module.Imports) != 0 {
-		return "", errors.New("Use of the `import` keyword is not allowed")
+	effectiveVersion := effectiveRegoVersion(module, c.regoVersion)
+	if err := checkImports(module, effectiveVersion); err != nil {
+		return "", err
 	}
 	// Temporarily unset Package.Path to avoid triggering a "prohibited data field" error
 	module.Package.Path = nil
-	if err := checkDataAccess(module); err != nil {
+	if err := checkDataAccess(module, c.dataRoots()); err != nil {
 		return "", err
 	}
 	module.Package.Path = packageRef(path)
+
+	if schemaSet := c.buildSchemaSet(); schemaSet != nil {
+		compiler := ast.NewCompiler().WithSchemas(schemaSet).WithUseTypeCheckAnnotations(true)
+		compiler.Compile(map[string]*ast.Module{kind: module})
+		if compiler.Failed() {
+			var errs Errors
+			for _, e := range compiler.Errors {
+				errs = append(errs, e)
+			}
+			return "", errs
+		}
+	}
+
 	return module.String(), nil
 }
 
+// dataRoots returns the set of `data.<root>` fields this client allows access to beyond the
+// built-in `data.inventory`, derived from its registered data root providers (and any data
+// schema registered without a provider, e.g. via WithDataSchema directly).
+func (c *Client) dataRoots() map[string]bool {
+	if len(c.dataSchemas) == 0 && len(c.dataRootProviders) == 0 {
+		return nil
+	}
+	roots := make(map[string]bool, len(c.dataSchemas)+len(c.dataRootProviders))
+	for root := range c.dataSchemas {
+		roots[root] = true
+	}
+	for root := range c.dataRootProviders {
+		roots[root] = true
+	}
+	return roots
+}
+
+// buildSchemaSet assembles the input/data schemas registered on c into an *ast.SchemaSet
+// suitable for ast.Compiler.WithSchemas, or nil if none are registered.
+func (c *Client) buildSchemaSet() *ast.SchemaSet {
+	if c.inputSchema == nil && len(c.dataSchemas) == 0 {
+		return nil
+	}
+	schemaSet := ast.NewSchemaSet()
+	if c.inputSchema != nil {
+		schemaSet.Put(ast.SchemaRootRef, c.inputSchema)
+	}
+	for root, schema := range c.dataSchemas {
+		schemaSet.Put(ast.MustParseRef("data."+root), schema)
+	}
+	return schemaSet
+}
+
+// effectiveRegoVersion returns the Rego version a module actually compiles under: a module
+// that declares `import rego.v1` always opts into v1 semantics, regardless of what the client
+// requested.
+func effectiveRegoVersion(module *ast.Module, requested ast.RegoVersion) ast.RegoVersion {
+	if declaresRegoV1(module) {
+		return ast.RegoV1
+	}
+	return requested
+}
+
+// declaresRegoV1 reports whether module imports `rego.v1`.
+func declaresRegoV1(module *ast.Module) bool {
+	for _, imp := range module.Imports {
+		if imp.Path.Value.(ast.Ref).String() == "rego.v1" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImports enforces the framework's import whitelist. Under v0 semantics no imports are
+// allowed at all. Under v1 semantics (either because the client requested it or because the
+// template opted in via `import rego.v1`) `rego.v1` and `future.keywords.*` are permitted, since
+// they are required to write idiomatic v1 Rego.
+func checkImports(module *ast.Module, version ast.RegoVersion) error {
+	if version != ast.RegoV1 {
+		if len(module.Imports) != 0 {
+			return errors.New("Use of the `import` keyword is not allowed")
+		}
+		return nil
+	}
+	for _, imp := range module.Imports {
+		name := imp.Path.Value.(ast.Ref).String()
+		if name == "rego.v1" || strings.HasPrefix(name, "future.keywords") {
+			continue
+		}
+		return fmt.Errorf("Use of the `import` keyword is not allowed, except for `rego.v1` and `future.keywords`: %s", name)
+	}
+	return nil
+}
+
 // packageRef constructs a Ref to the provided package path string
 func packageRef(path string) ast.Ref {
 	pathParts := strings.Split(path, ".")
@@ -61,12 +154,17 @@ func (errs Errors) Error() string {
 	return strings.Join(s, "\n")
 }
 
-// checkDataAccess makes sure that data is only referenced in terms of valid subfields
-func checkDataAccess(module *ast.Module) Errors {
-	// Currently rules should only access data.inventory
+// checkDataAccess makes sure that data is only referenced in terms of valid subfields.
+// extraRoots additionally allows any `data.<root>` registered via Client.AddDataRootProvider
+// (or WithDataSchema directly).
+func checkDataAccess(module *ast.Module, extraRoots map[string]bool) Errors {
+	// Rules may always access data.inventory; other roots must be registered first.
 	validDataFields := map[string]bool{
 		"inventory": true,
 	}
+	for root := range extraRoots {
+		validDataFields[root] = true
+	}
 
 	var errs Errors
 	ast.WalkRefs(module, func(r ast.Ref) bool {
@@ -99,68 +197,238 @@ func checkDataAccess(module *ast.Module) Errors {
 	return nil
 }
 
-// rule name -> arity
-type ruleArities map[string]int
+// RuleKind distinguishes the shapes a rule head can take.
+type RuleKind int
+
+const (
+	// RuleKindUnspecified is the zero value of RuleKind. In a RuleRequirement it means "don't
+	// check the rule's kind", so a RuleRequirement built without setting Kind doesn't silently
+	// assert RuleKindComplete.
+	RuleKindUnspecified RuleKind = iota
+	// RuleKindComplete is a complete rule, e.g. `p = v { ... }` or bare `p { ... }`.
+	RuleKindComplete
+	// RuleKindFunction is a function rule, e.g. `f(x, y) = v { ... }`.
+	RuleKindFunction
+	// RuleKindPartialSet is a partial set rule, e.g. `p[x] { ... }`.
+	RuleKindPartialSet
+	// RuleKindPartialObject is a partial object rule, e.g. `p[x] = v { ... }`.
+	RuleKindPartialObject
+)
+
+func (k RuleKind) String() string {
+	switch k {
+	case RuleKindComplete:
+		return "complete"
+	case RuleKindFunction:
+		return "function"
+	case RuleKindPartialSet:
+		return "partial set"
+	case RuleKindPartialObject:
+		return "partial object"
+	default:
+		return "unspecified"
+	}
+}
+
+// RuleSignature describes the shape of a rule head: its positional arity, whether it's a
+// function vs. a partial set/object rule, and -- if the rule builds its result directly in the
+// head -- which keys are statically knowable. This lets template authors and the engine agree
+// on richer result contracts (e.g. requiring a rule to return an object with `msg` and
+// `details` keys) and catch drift at template registration time rather than in the response
+// marshaling path.
+type RuleSignature struct {
+	// Arity is the number of positional elements in the rule head: len(Head.Args) for a
+	// function, or the number of elements in Head.Key's array for a partial set or partial
+	// object rule with an array key. 1 for a partial rule whose key is a single variable or an
+	// object literal, 0 for a complete rule.
+	Arity int
+
+	// Kind is the rule's head shape; see RuleKind.
+	Kind RuleKind
 
-// requireRules makes sure the listed rules are specified with the required arity
-func requireRules(name, rego string, reqs ruleArities) error {
-	module, err := ast.ParseModule(name, rego)
+	// ReviewBuilder is true when the rule's final positional element is an object literal,
+	// i.e. the rule constructs its result object directly in the head, e.g.
+	// `violation[{"msg": msg, "details": details}] { ... }`.
+	ReviewBuilder bool
+
+	// ReviewKeys holds the statically knowable keys of the review-builder object, when
+	// ReviewBuilder is true. Keys that aren't literal strings can't be known statically and
+	// are omitted.
+	ReviewKeys map[string]bool
+}
+
+// RuleRequirement describes the shape a named rule must have for requireRules to accept a
+// template's registration.
+type RuleRequirement struct {
+	// Kind is the required RuleKind. RuleKindUnspecified (the zero value) skips the check.
+	Kind RuleKind
+	// Arity is the required positional arity.
+	Arity int
+	// RequiredKeys, if non-empty, requires the rule to be a review-builder (see
+	// RuleSignature.ReviewBuilder) whose object defines at least these keys.
+	RequiredKeys []string
+}
+
+// requireRules makes sure the listed rules are registered and match their required
+// RuleRequirement. c.regoVersion selects the Rego dialect used to parse and compile rego; a
+// template that declares `import rego.v1` is compiled as v1 regardless of c.regoVersion. The
+// effective version is returned so callers (e.g. the driver, when recording a trace/dump) know
+// which dialect was used. If c carries input/data schemas, the signature check is joined by a
+// full compile pass with schema type-check annotations, so schema violations surface as
+// template registration errors. The compiler runs in strict mode so use of a deprecated
+// builtin is also a registration error rather than a silent acceptance.
+func (c *Client) requireRules(name, rego string, reqs map[string]RuleRequirement) (ast.RegoVersion, error) {
+	module, err := ast.ParseModuleWithOpts(name, rego, ast.ParserOptions{RegoVersion: c.regoVersion})
 	if err != nil {
-		return err
+		return c.regoVersion, err
 	}
+	effectiveVersion := effectiveRegoVersion(module, c.regoVersion)
 
-	arities := make(ruleArities, len(module.Rules))
+	compiler := ast.NewCompiler().WithCapabilities(ast.CapabilitiesForThisVersion()).WithStrict(true)
+	if schemaSet := c.buildSchemaSet(); schemaSet != nil {
+		compiler = compiler.WithSchemas(schemaSet).WithUseTypeCheckAnnotations(true)
+	}
+	compiler.Compile(map[string]*ast.Module{name: module})
+	if compiler.Failed() {
+		var compileErrs Errors
+		for _, e := range compiler.Errors {
+			compileErrs = append(compileErrs, e)
+		}
+		return effectiveVersion, compileErrs
+	}
+
+	signatures := make(map[string]RuleSignature, len(module.Rules))
 	for _, rule := range module.Rules {
-		name := string(rule.Head.Name)
-		arity, err := getRuleArity(rule)
+		sig, err := getRuleSignature(rule)
 		if err != nil {
-			return err
+			return effectiveVersion, err
 		}
-		arities[name] = arity
+		signatures[string(rule.Head.Name)] = sig
 	}
 
 	var errs Errors
-	for name, arity := range reqs {
-		actual, ok := arities[name]
+	for name, req := range reqs {
+		sig, ok := signatures[name]
 		if !ok {
 			errs = append(errs, fmt.Errorf("Missing required rule: %s", name))
 			continue
 		}
-		if arity != actual {
-			errs = append(errs, fmt.Errorf("Rule %s has arity %d, want %d", name, actual, arity))
+		if req.Kind != RuleKindUnspecified && sig.Kind != req.Kind {
+			errs = append(errs, fmt.Errorf("Rule %s has kind %v, want %v", name, sig.Kind, req.Kind))
+		}
+		if sig.Arity != req.Arity {
+			errs = append(errs, fmt.Errorf("Rule %s has arity %d, want %d", name, sig.Arity, req.Arity))
+		}
+		if len(req.RequiredKeys) == 0 {
+			continue
+		}
+		if !sig.ReviewBuilder {
+			errs = append(errs, fmt.Errorf("Rule %s must build its result object in the rule head, e.g. {%q: ...}", name, req.RequiredKeys[0]))
+			continue
+		}
+		var missing []string
+		for _, k := range req.RequiredKeys {
+			if !sig.ReviewKeys[k] {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, fmt.Errorf("Rule %s is missing required result key(s): %s", name, strings.Join(missing, ", ")))
 		}
 	}
 	if len(errs) != 0 {
-		return errs
+		return effectiveVersion, errs
 	}
 
-	return nil
+	return effectiveVersion, nil
 }
 
-// getRuleArity returns the arity of a rule, assuming only no variables, a single variable, or
-// an array of variables
-func getRuleArity(r *ast.Rule) (int, error) {
-	t := r.Head.Key
-	if t == nil {
-		return 0, nil
+// getRuleSignature determines r's RuleSignature, assuming only a function head, a bare
+// variable, an object literal, or an array of variables/a trailing object literal in the
+// partial-rule key.
+func getRuleSignature(r *ast.Rule) (RuleSignature, error) {
+	switch {
+	case len(r.Head.Args) > 0:
+		sig := RuleSignature{Arity: len(r.Head.Args), Kind: RuleKindFunction}
+		applyReviewBuilder(&sig, r.Head.Value)
+		return sig, nil
+	case r.Head.Key != nil && r.Head.Value != nil:
+		arity, err := partialObjectKeyArity(r.Head.Key)
+		if err != nil {
+			return RuleSignature{}, err
+		}
+		sig := RuleSignature{Arity: arity, Kind: RuleKindPartialObject}
+		applyReviewBuilder(&sig, r.Head.Value)
+		return sig, nil
+	case r.Head.Key != nil:
+		return getPartialSetSignature(r.Head.Key)
+	default:
+		return RuleSignature{Kind: RuleKindComplete}, nil
 	}
-	switch v := t.Value.(type) {
+}
+
+// partialObjectKeyArity returns the arity of a partial object rule's key, e.g. `p[ns] = v` (1)
+// or a composite key `p[[ns, name]] = v` (len of the array). Unlike a partial set's key, the
+// key of a partial object rule never builds the review object itself -- that's Head.Value's
+// job -- so every element must be a plain variable.
+func partialObjectKeyArity(key *ast.Term) (int, error) {
+	switch v := key.Value.(type) {
 	case ast.Var:
 		return 1, nil
 	case ast.Array:
-		errs := false
 		for _, e := range v {
 			if _, ok := e.Value.(ast.Var); !ok {
-				// for multi-arity args, a dev may be building the review object in the head of the rule
-				if _, ok := e.Value.(ast.Object); !ok {
-					errs = true
-				}
+				return 0, fmt.Errorf("Invalid rule signature: only variables allowed in a composite key: %s", v.String())
 			}
 		}
-		if errs {
-			return 0, fmt.Errorf("Invalid rule signature: only single variables or arrays of variables or objects allowed: %s", v.String())
-		}
 		return len(v), nil
 	}
-	return 0, fmt.Errorf("Invalid rule signature, only variables or arrays allowed: %s", t.String())
+	return 0, fmt.Errorf("Invalid rule signature, only a variable or an array of variables allowed as a key: %s", key.String())
+}
+
+// getPartialSetSignature handles the `p[key] { ... }` family: key may be a bare variable, an
+// object literal building the result directly (`p[{"msg": msg}]`), or an array of variables
+// with an optional trailing object literal (`p[[user, {"msg": msg}]]`).
+func getPartialSetSignature(key *ast.Term) (RuleSignature, error) {
+	switch v := key.Value.(type) {
+	case ast.Var:
+		return RuleSignature{Arity: 1, Kind: RuleKindPartialSet}, nil
+	case ast.Object:
+		sig := RuleSignature{Arity: 1, Kind: RuleKindPartialSet}
+		applyReviewBuilder(&sig, key)
+		return sig, nil
+	case ast.Array:
+		sig := RuleSignature{Arity: len(v), Kind: RuleKindPartialSet}
+		for i, e := range v {
+			switch e.Value.(type) {
+			case ast.Var:
+			case ast.Object:
+				// a dev may be building the review object in the head of the rule; only the
+				// final element is treated as the rule's review builder
+				if i == len(v)-1 {
+					applyReviewBuilder(&sig, e)
+				}
+			default:
+				return RuleSignature{}, fmt.Errorf("Invalid rule signature: only single variables or arrays of variables or objects allowed: %s", v.String())
+			}
+		}
+		return sig, nil
+	}
+	return RuleSignature{}, fmt.Errorf("Invalid rule signature, only variables or arrays allowed: %s", key.String())
+}
+
+// applyReviewBuilder marks sig as a review-builder and records t's statically knowable keys if
+// t is an object literal; it is a no-op otherwise.
+func applyReviewBuilder(sig *RuleSignature, t *ast.Term) {
+	obj, ok := t.Value.(ast.Object)
+	if !ok {
+		return
+	}
+	sig.ReviewBuilder = true
+	sig.ReviewKeys = make(map[string]bool, obj.Len())
+	obj.Foreach(func(k, _ *ast.Term) {
+		if s, ok := k.Value.(ast.String); ok {
+			sig.ReviewKeys[string(s)] = true
+		}
+	})
 }
@@ -0,0 +1,385 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+func mustParseSchema(t *testing.T, raw string) *ast.Schema {
+	t.Helper()
+	schema := &ast.Schema{}
+	if err := json.Unmarshal([]byte(raw), schema); err != nil {
+		t.Fatalf("parsing schema: %v", err)
+	}
+	return schema
+}
+
+func TestEnsureRegoConformance_V0RejectsImport(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+import future.keywords.if
+
+violation[{"msg": "hi"}] {
+	true
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err == nil {
+		t.Fatal("expected an error for `import` under the default (v0) client")
+	} else if !strings.Contains(err.Error(), "import") {
+		t.Fatalf("got error %q, want it to mention `import`", err.Error())
+	}
+}
+
+func TestEnsureRegoConformance_RegoV1OptInViaImport(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+import rego.v1
+
+violation contains {"msg": "hi"} if {
+	true
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err != nil {
+		t.Fatalf("ensureRegoConformance: %v", err)
+	}
+}
+
+func TestEnsureRegoConformance_ClientRequestedV1AllowsFutureKeywords(t *testing.T) {
+	c, err := NewClient(RegoVersion(ast.RegoV1))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+import future.keywords.if
+
+violation contains {"msg": "hi"} if {
+	true
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err != nil {
+		t.Fatalf("ensureRegoConformance: %v", err)
+	}
+}
+
+type fakeDataRootProvider struct {
+	name string
+}
+
+func (p fakeDataRootProvider) Name() string { return p.name }
+
+type fakeSchemaDataRootProvider struct {
+	fakeDataRootProvider
+	schema *ast.Schema
+}
+
+func (p fakeSchemaDataRootProvider) Schema() *ast.Schema { return p.schema }
+
+var _ SchemaDataRootProvider = fakeSchemaDataRootProvider{}
+
+type fakeResolvableDataRootProvider struct {
+	fakeDataRootProvider
+}
+
+func (p fakeResolvableDataRootProvider) Resolve(_ context.Context, _ ast.Ref) (interface{}, error) {
+	return nil, nil
+}
+
+var _ ResolvableDataRootProvider = fakeResolvableDataRootProvider{}
+
+func TestAddDataRootProvider_SchemaProviderPopulatesDataSchemas(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	schema := &ast.Schema{}
+	p := fakeSchemaDataRootProvider{fakeDataRootProvider{name: "imagescancache"}, schema}
+	if err := c.AddDataRootProvider(p); err != nil {
+		t.Fatalf("AddDataRootProvider: %v", err)
+	}
+	if c.dataSchemas["imagescancache"] != schema {
+		t.Fatal("expected AddDataRootProvider to populate dataSchemas from the provider's Schema()")
+	}
+	if schemaSet := c.buildSchemaSet(); schemaSet == nil {
+		t.Fatal("expected the provider's schema to feed into buildSchemaSet")
+	}
+}
+
+func TestAddDataRootProvider_ResolvableProviderRegistersRoot(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	p := fakeResolvableDataRootProvider{fakeDataRootProvider{name: "imagescancache"}}
+	if err := c.AddDataRootProvider(p); err != nil {
+		t.Fatalf("AddDataRootProvider: %v", err)
+	}
+	if roots := c.dataRoots(); !roots["imagescancache"] {
+		t.Fatal("expected a resolvable provider's root to be allowed like any other data root provider")
+	}
+	if _, ok := c.dataSchemas["imagescancache"]; ok {
+		t.Fatal("a provider with no Schema() method shouldn't populate dataSchemas")
+	}
+}
+
+func TestEnsureRegoConformance_UnregisteredDataRootRejected(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": "hi"}] {
+	data.imagescancache.foo == "bar"
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err == nil {
+		t.Fatal("expected an error for an unregistered `data` root")
+	}
+}
+
+func TestEnsureRegoConformance_RegisteredDataRootProviderAllowed(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.AddDataRootProvider(fakeDataRootProvider{name: "imagescancache"}); err != nil {
+		t.Fatalf("AddDataRootProvider: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": "hi"}] {
+	data.imagescancache.foo == "bar"
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err != nil {
+		t.Fatalf("ensureRegoConformance: %v", err)
+	}
+}
+
+func TestRequireRules_MultiArityReviewBuilderRoundTrips(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[[user, {"msg": msg, "details": {"a": 1}}]] {
+	user := "alice"
+	msg := "hi"
+}
+`
+	reqs := map[string]RuleRequirement{
+		"violation": {
+			Kind:         RuleKindPartialSet,
+			Arity:        2,
+			RequiredKeys: []string{"msg", "details"},
+		},
+	}
+	if _, err := c.requireRules("test.rego", rego, reqs); err != nil {
+		t.Fatalf("requireRules: %v", err)
+	}
+}
+
+func TestRequireRules_MissingRequiredKeyIsRegistrationError(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[[user, {"msg": msg}]] {
+	user := "alice"
+	msg := "hi"
+}
+`
+	reqs := map[string]RuleRequirement{
+		"violation": {
+			Kind:         RuleKindPartialSet,
+			Arity:        2,
+			RequiredKeys: []string{"msg", "details"},
+		},
+	}
+	if _, err := c.requireRules("test.rego", rego, reqs); err == nil {
+		t.Fatal("expected a missing `details` key to be a registration error")
+	}
+}
+
+func TestRequireRules_DeprecatedBuiltinIsRegistrationError(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": "hi"}] {
+	any([true, false])
+}
+`
+	reqs := map[string]RuleRequirement{
+		"violation": {Kind: RuleKindPartialSet, Arity: 1, RequiredKeys: []string{"msg"}},
+	}
+	if _, err := c.requireRules("test.rego", rego, reqs); err == nil {
+		t.Fatal("expected use of the deprecated `any` builtin to fail registration under strict mode")
+	}
+}
+
+func TestRequireRules_KindUnspecifiedSkipsKindCheck(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": msg}] {
+	msg := "hi"
+}
+`
+	reqs := map[string]RuleRequirement{
+		"violation": {Arity: 1, RequiredKeys: []string{"msg"}},
+	}
+	if _, err := c.requireRules("test.rego", rego, reqs); err != nil {
+		t.Fatalf("requireRules: %v", err)
+	}
+}
+
+func TestGetRuleSignature_ReviewBuilderObjectNotInLastPosition(t *testing.T) {
+	module, err := ast.ParseModule("test.rego", `package foo
+
+violation[[{"msg": "hi"}, user]] {
+	user := "alice"
+}
+`)
+	if err != nil {
+		t.Fatalf("ParseModule: %v", err)
+	}
+	sig, err := getRuleSignature(module.Rules[0])
+	if err != nil {
+		t.Fatalf("getRuleSignature: %v", err)
+	}
+	if sig.Arity != 2 {
+		t.Fatalf("got arity %d, want 2", sig.Arity)
+	}
+	if sig.ReviewBuilder {
+		t.Fatal("expected ReviewBuilder to be false when the object isn't the final element")
+	}
+}
+
+func TestGetRuleSignature_PartialObjectCompositeKeyArity(t *testing.T) {
+	module, err := ast.ParseModule("test.rego", `package foo
+
+p[[ns, name]] = {"msg": "hi"} {
+	ns := "default"
+	name := "foo"
+}
+`)
+	if err != nil {
+		t.Fatalf("ParseModule: %v", err)
+	}
+	sig, err := getRuleSignature(module.Rules[0])
+	if err != nil {
+		t.Fatalf("getRuleSignature: %v", err)
+	}
+	if sig.Kind != RuleKindPartialObject {
+		t.Fatalf("got kind %v, want %v", sig.Kind, RuleKindPartialObject)
+	}
+	if sig.Arity != 2 {
+		t.Fatalf("got arity %d, want 2", sig.Arity)
+	}
+	if !sig.ReviewBuilder || !sig.ReviewKeys["msg"] {
+		t.Fatal("expected Head.Value's object literal to be recognized as the review builder")
+	}
+}
+
+func TestEnsureRegoConformance_InputSchemaViolationIsRegistrationError(t *testing.T) {
+	inputSchema := mustParseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"review": {
+				"type": "object",
+				"properties": {
+					"object": {"type": "object"}
+				},
+				"additionalProperties": false
+			}
+		},
+		"additionalProperties": false
+	}`)
+	c, err := NewClient(WithInputSchema(inputSchema))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": "hi"}] {
+	input.review.badField == true
+}
+`
+	if _, err := c.ensureRegoConformance("test.rego", "foo", rego); err == nil {
+		t.Fatal("expected referencing a field absent from the input schema to fail registration")
+	}
+}
+
+func TestRequireRules_DataSchemaViolationIsRegistrationError(t *testing.T) {
+	cacheSchema := mustParseSchema(t, `{
+		"type": "object",
+		"properties": {
+			"scans": {"type": "array"}
+		},
+		"additionalProperties": false
+	}`)
+	c, err := NewClient(WithDataSchema("imagescancache", cacheSchema))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.AddDataRootProvider(fakeDataRootProvider{name: "imagescancache"}); err != nil {
+		t.Fatalf("AddDataRootProvider: %v", err)
+	}
+	rego := `package foo
+
+violation[{"msg": "hi"}] {
+	data.imagescancache.badField == true
+}
+`
+	reqs := map[string]RuleRequirement{
+		"violation": {Kind: RuleKindPartialSet, Arity: 1, RequiredKeys: []string{"msg"}},
+	}
+	if _, err := c.requireRules("test.rego", rego, reqs); err == nil {
+		t.Fatal("expected referencing a field absent from the data schema to fail registration")
+	}
+}
+
+func TestClient_SchemaOptsPopulateSchemaSet(t *testing.T) {
+	inputSchema := &ast.Schema{}
+	cacheSchema := &ast.Schema{}
+
+	c, err := NewClient(WithInputSchema(inputSchema), WithDataSchema("cache", cacheSchema))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if c.inputSchema != inputSchema {
+		t.Fatal("WithInputSchema did not set Client.inputSchema")
+	}
+	if c.dataSchemas["cache"] != cacheSchema {
+		t.Fatal("WithDataSchema did not set Client.dataSchemas")
+	}
+	if schemaSet := c.buildSchemaSet(); schemaSet == nil {
+		t.Fatal("expected a non-nil schema set once a schema is registered")
+	}
+	if (&Client{}).buildSchemaSet() != nil {
+		t.Fatal("expected a nil schema set for a client with no registered schemas")
+	}
+}
@@ -22,6 +22,41 @@ type Result struct {
 
 	// The violating Resource, filled out by the Target
 	Resource interface{}
+
+	// EnforcementAction is the action to take for this result at every enforcement point
+	// when ScopedEnforcementActions is empty.
+	EnforcementAction string `json:"enforcementAction,omitempty"`
+
+	// ScopedEnforcementActions allows a constraint to carry a different enforcement action
+	// per enforcement point, e.g. `deny` for the validating webhook but `warn` for audit. If
+	// this list is non-empty, the result applies only at the enforcement points its scopes
+	// list; EnforcementAction is not used for any other point.
+	ScopedEnforcementActions []ScopedAction `json:"scopedEnforcementActions,omitempty"`
+}
+
+// ScopedAction binds an enforcement Action to the set of EnforcementPoints it applies to, e.g.
+// `audit` or `webhook.k8s.gatekeeper.sh`.
+type ScopedAction struct {
+	Action            string   `json:"action,omitempty"`
+	EnforcementPoints []string `json:"enforcementPoints,omitempty"`
+}
+
+// ActionForEnforcementPoint returns the enforcement action r carries for the given
+// enforcement point, and whether r applies there at all. A Result with no scoped actions
+// applies everywhere, under its EnforcementAction. A Result with scoped actions applies only
+// at the enforcement points one of its scopes lists.
+func (r *Result) ActionForEnforcementPoint(enforcementPoint string) (string, bool) {
+	if len(r.ScopedEnforcementActions) == 0 {
+		return r.EnforcementAction, true
+	}
+	for _, scoped := range r.ScopedEnforcementActions {
+		for _, ep := range scoped.EnforcementPoints {
+			if ep == enforcementPoint {
+				return scoped.Action, true
+			}
+		}
+	}
+	return "", false
 }
 
 type Response struct {
@@ -50,6 +85,32 @@ func (r *Response) TraceDump() string {
 	return b.String()
 }
 
+// enforcementActionSeverity ranks well-known enforcement actions from most to least severe.
+// Actions not in this list are treated as the least severe.
+var enforcementActionSeverity = map[string]int{
+	"deny":   3,
+	"warn":   2,
+	"dryrun": 1,
+}
+
+// HighestSeverityForPoint returns the most severe enforcement action carried by r.Results for
+// the given enforcement point, and whether any result applies there at all.
+func (r *Response) HighestSeverityForPoint(enforcementPoint string) (string, bool) {
+	var highest string
+	found := false
+	for _, res := range r.Results {
+		action, ok := res.ActionForEnforcementPoint(enforcementPoint)
+		if !ok {
+			continue
+		}
+		if !found || enforcementActionSeverity[action] > enforcementActionSeverity[highest] {
+			highest = action
+			found = true
+		}
+	}
+	return highest, found
+}
+
 type Responses map[string]*Response
 
 func (r *Responses) Results() []*Result {
@@ -60,6 +121,28 @@ func (r *Responses) Results() []*Result {
 	return res
 }
 
+// FilterByEnforcementPoint returns a copy of r containing only the Results that apply to
+// enforcementPoint, per each Result's ActionForEnforcementPoint. Target Responses with no
+// matching Results are dropped entirely.
+func (r *Responses) FilterByEnforcementPoint(enforcementPoint string) *Responses {
+	filtered := make(Responses, len(*r))
+	for target, resp := range *r {
+		var results []*Result
+		for _, res := range resp.Results {
+			if _, ok := res.ActionForEnforcementPoint(enforcementPoint); ok {
+				results = append(results, res)
+			}
+		}
+		if len(results) == 0 {
+			continue
+		}
+		filteredResp := *resp
+		filteredResp.Results = results
+		filtered[target] = &filteredResp
+	}
+	return &filtered
+}
+
 func (r *Responses) TraceDump() string {
 	b := &strings.Builder{}
 	for _, resp := range *r {
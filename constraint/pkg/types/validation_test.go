@@ -0,0 +1,83 @@
+package types
+
+import "testing"
+
+func TestResult_ActionForEnforcementPoint(t *testing.T) {
+	scoped := &Result{
+		EnforcementAction: "warn",
+		ScopedEnforcementActions: []ScopedAction{
+			{Action: "deny", EnforcementPoints: []string{"webhook.k8s.gatekeeper.sh"}},
+		},
+	}
+	if action, ok := scoped.ActionForEnforcementPoint("webhook.k8s.gatekeeper.sh"); !ok || action != "deny" {
+		t.Fatalf("got (%q, %v), want (\"deny\", true)", action, ok)
+	}
+	if _, ok := scoped.ActionForEnforcementPoint("audit"); ok {
+		t.Fatalf("expected no scope to match \"audit\"")
+	}
+
+	unscoped := &Result{EnforcementAction: "warn"}
+	if action, ok := unscoped.ActionForEnforcementPoint("audit"); !ok || action != "warn" {
+		t.Fatalf("got (%q, %v), want (\"warn\", true)", action, ok)
+	}
+}
+
+func TestResponse_HighestSeverityForPoint(t *testing.T) {
+	resp := &Response{
+		Results: []*Result{
+			{EnforcementAction: "warn"},
+			{EnforcementAction: "deny"},
+			{
+				EnforcementAction: "deny",
+				ScopedEnforcementActions: []ScopedAction{
+					{Action: "dryrun", EnforcementPoints: []string{"audit"}},
+				},
+			},
+		},
+	}
+	if action, ok := resp.HighestSeverityForPoint("webhook.k8s.gatekeeper.sh"); !ok || action != "deny" {
+		t.Fatalf("got (%q, %v), want (\"deny\", true)", action, ok)
+	}
+	if action, ok := resp.HighestSeverityForPoint("audit"); !ok || action != "deny" {
+		t.Fatalf("got (%q, %v), want (\"deny\", true)", action, ok)
+	}
+	if _, ok := (&Response{}).HighestSeverityForPoint("audit"); ok {
+		t.Fatalf("expected no results to mean no match")
+	}
+}
+
+func TestResponses_FilterByEnforcementPoint(t *testing.T) {
+	responses := Responses{
+		"target-a": {
+			Results: []*Result{
+				{
+					EnforcementAction: "warn",
+					ScopedEnforcementActions: []ScopedAction{
+						{Action: "deny", EnforcementPoints: []string{"webhook.k8s.gatekeeper.sh"}},
+					},
+				},
+			},
+		},
+		"target-b": {
+			Results: []*Result{
+				{
+					ScopedEnforcementActions: []ScopedAction{
+						{Action: "warn", EnforcementPoints: []string{"audit"}},
+					},
+				},
+			},
+		},
+	}
+
+	filtered := responses.FilterByEnforcementPoint("webhook.k8s.gatekeeper.sh")
+	if len(*filtered) != 1 {
+		t.Fatalf("got %d responses, want 1", len(*filtered))
+	}
+	resp, ok := (*filtered)["target-a"]
+	if !ok {
+		t.Fatalf("expected target-a to survive the filter")
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(resp.Results))
+	}
+}